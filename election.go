@@ -0,0 +1,162 @@
+package raft
+
+import "time"
+
+// EnablePreVote turns on the PreVote phase for this server's elections. Off
+// by default for compatibility with peers that don't understand
+// PreVoteRequest; enable it on every server in the cluster to stop
+// partitioned peers from inflating currentTerm every time they rejoin.
+func (s *server) EnablePreVote(enable bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.preVoteEnabled = enable
+}
+
+// elect is the entry point for attempting an election, in place of bumping
+// currentTerm and calling sendVoteRequest directly. When PreVote is enabled
+// it first runs a non-disruptive round to gauge whether a real election
+// would actually be won; currentTerm is only bumped, and real votes only
+// requested, if that gauge comes back favorable. Returns whether the
+// election was won.
+//
+// The normal candidate/election-timeout loop that starts elections on its
+// own (as opposed to processTimeoutNowRequest's targeted trigger) lives
+// outside the files in this tree and isn't reachable from here; it still
+// needs to call elect() instead of inlining its own term bump for PreVote to
+// actually gate disruptive elections in the common case.
+func (s *server) elect() bool {
+	s.mutex.RLock()
+	preVoteEnabled := s.preVoteEnabled
+	s.mutex.RUnlock()
+
+	if preVoteEnabled && !s.runPreVote() {
+		debugln("raft.election.prevote.failed: ", s.name)
+		return false
+	}
+
+	return s.runVote()
+}
+
+// runPreVote asks every voting peer whether they would grant a real vote
+// for currentTerm+1 without bumping currentTerm or persisting any state on
+// either side. Returns whether a majority would.
+func (s *server) runPreVote() bool {
+	s.mutex.RLock()
+	term := s.currentTerm + 1
+	name := s.name
+	lastLogIndex := s.log.currentIndex()
+	lastLogTerm := s.log.lastTerm()
+	voters := s.votingPeers()
+	s.mutex.RUnlock()
+
+	if len(voters) == 0 {
+		return true
+	}
+
+	c := make(chan *PreVoteResponse, len(voters))
+	for _, peer := range voters {
+		go peer.sendPreVoteRequest(newPreVoteRequest(term, name, lastLogIndex, lastLogTerm), c)
+	}
+
+	granted, total := 1, len(voters)+1 // count self
+	timeout := time.After(s.ElectionTimeout())
+	for i := 0; i < len(voters); i++ {
+		select {
+		case resp := <-c:
+			if resp.VoteGranted {
+				granted++
+			}
+		case <-timeout:
+			i = len(voters)
+		}
+	}
+
+	return granted*2 > total
+}
+
+// processPreVoteRequest answers a PreVoteRequest without persisting any
+// state or bumping currentTerm: it reports whether the receiver would grant
+// a real vote for req.Term given the candidate's log, so a candidate can
+// gauge its odds before paying the cost of a disruptive term bump.
+func (s *server) processPreVoteRequest(req *PreVoteRequest) *PreVoteResponse {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if req.Term < s.currentTerm {
+		return newPreVoteResponse(s.currentTerm, false)
+	}
+
+	lastLogIndex := s.log.currentIndex()
+	lastLogTerm := s.log.lastTerm()
+	upToDate := logUpToDate(req.LastLogTerm, req.LastLogIndex, lastLogTerm, lastLogIndex)
+
+	return newPreVoteResponse(s.currentTerm, upToDate)
+}
+
+// logUpToDate reports whether a candidate's log (candidateTerm,
+// candidateIndex) is at least as up to date as the receiver's own last log
+// entry (ourTerm, ourIndex), per the Raft up-to-date check (§5.4.1): the
+// log with the later last-entry term wins outright; on a tie, the longer
+// log wins. Shared by processPreVoteRequest and, when RequestVote is
+// implemented, the equivalent real-vote check.
+func logUpToDate(candidateTerm, candidateIndex, ourTerm, ourIndex uint64) bool {
+	return candidateTerm > ourTerm || (candidateTerm == ourTerm && candidateIndex >= ourIndex)
+}
+
+// votingPeers returns the peers that count towards an election or
+// read-quorum tally, excluding learners: they receive replication traffic
+// like any other peer, but don't get a vote until PromoteLearner is called
+// for them. Callers must hold s.mutex.
+func (s *server) votingPeers() map[string]*Peer {
+	return filterVotingPeers(s.peers)
+}
+
+// filterVotingPeers returns the subset of peers that aren't learners. Split
+// out from votingPeers so the learner-exclusion rule is covered directly in
+// learner_test.go without needing a *server to call it on.
+func filterVotingPeers(peers map[string]*Peer) map[string]*Peer {
+	voters := make(map[string]*Peer, len(peers))
+	for name, peer := range peers {
+		if !peer.IsLearner {
+			voters[name] = peer
+		}
+	}
+	return voters
+}
+
+// runVote bumps currentTerm and runs the real RequestVote round that
+// PreVote, when enabled, gates.
+func (s *server) runVote() bool {
+	s.mutex.Lock()
+	s.currentTerm++
+	term := s.currentTerm
+	name := s.name
+	lastLogIndex := s.log.currentIndex()
+	lastLogTerm := s.log.lastTerm()
+	voters := s.votingPeers()
+	s.mutex.Unlock()
+
+	if len(voters) == 0 {
+		return true
+	}
+
+	c := make(chan *RequestVoteResponse, len(voters))
+	for _, peer := range voters {
+		go peer.sendVoteRequest(newRequestVoteRequest(term, name, lastLogIndex, lastLogTerm), c)
+	}
+
+	granted, total := 1, len(voters)+1 // count self
+	timeout := time.After(s.ElectionTimeout())
+	for i := 0; i < len(voters); i++ {
+		select {
+		case resp := <-c:
+			if resp.VoteGranted {
+				granted++
+			}
+		case <-timeout:
+			i = len(voters)
+		}
+	}
+
+	return granted*2 > total
+}
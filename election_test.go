@@ -0,0 +1,28 @@
+package raft
+
+import "testing"
+
+func TestLogUpToDate(t *testing.T) {
+	tests := []struct {
+		name                          string
+		candidateTerm, candidateIndex uint64
+		ourTerm, ourIndex             uint64
+		want                          bool
+	}{
+		{"candidate term ahead wins even with shorter log", 5, 1, 4, 100, true},
+		{"candidate term behind loses even with longer log", 4, 100, 5, 1, false},
+		{"same term, candidate log at least as long wins", 4, 10, 4, 10, true},
+		{"same term, candidate log longer wins", 4, 11, 4, 10, true},
+		{"same term, candidate log shorter loses", 4, 9, 4, 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := logUpToDate(tt.candidateTerm, tt.candidateIndex, tt.ourTerm, tt.ourIndex)
+			if got != tt.want {
+				t.Errorf("logUpToDate(%d, %d, %d, %d) = %v, want %v",
+					tt.candidateTerm, tt.candidateIndex, tt.ourTerm, tt.ourIndex, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package raft
+
+// PipelineFlushEventType is fired each time a pipelined AppendEntries RPC
+// for a peer is sent, mirroring HeartbeatEventType for the lock-step path.
+const PipelineFlushEventType = "pipelineFlush"
+
+// SnapshotChunkEventType is fired each time a snapshot chunk is sent to a
+// peer during a chunked InstallSnapshot transfer.
+const SnapshotChunkEventType = "snapshotChunk"
+
+// LearnerPromotedEventType is fired when a learner's log lag falls within
+// DefaultLearnerPromotionLagThreshold of the leader's log, signalling that
+// it is now safe for an operator to call Server.PromoteLearner.
+const LearnerPromotedEventType = "learnerPromoted"
@@ -0,0 +1,112 @@
+package raft
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// NewGRPCServer creates a *grpc.Server configured with the gob codec
+// GRPCTransporter's client side dials with, so the handlers Install
+// registers decode requests correctly. Wire it up with:
+//
+//	srv := raft.NewGRPCServer()
+//	transporter.Install(server, srv)
+//	lis, _ := net.Listen("tcp", addr)
+//	srv.Serve(lis)
+func NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.ForceServerCodec(gobCodec{})}, opts...)
+	return grpc.NewServer(opts...)
+}
+
+// Install registers every RPC GRPCTransporter's Send* methods dial out to
+// as a handler on srv, so a server built with NewGRPCServer can actually
+// answer them instead of GRPCTransporter only ever being usable to dial
+// out. AppendEntries is registered as a stream, matching the client's
+// long-lived per-peer stream in ensureAppendEntriesStream; every other RPC
+// is a plain unary call.
+func (t *GRPCTransporter) Install(s *server, srv *grpc.Server) {
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: raftServiceName,
+		HandlerType: (*GRPCTransporter)(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "RequestVote", Handler: grpcUnaryHandler(
+				func() interface{} { return &RequestVoteRequest{} },
+				func(req interface{}) interface{} { return s.RequestVote(req.(*RequestVoteRequest)) },
+			)},
+			{MethodName: "PreVote", Handler: grpcUnaryHandler(
+				func() interface{} { return &PreVoteRequest{} },
+				func(req interface{}) interface{} { return s.processPreVoteRequest(req.(*PreVoteRequest)) },
+			)},
+			{MethodName: "Snapshot", Handler: grpcUnaryHandler(
+				func() interface{} { return &SnapshotRequest{} },
+				func(req interface{}) interface{} { return s.SnapshotRequest(req.(*SnapshotRequest)) },
+			)},
+			{MethodName: "SnapshotRecovery", Handler: grpcUnaryHandler(
+				func() interface{} { return &SnapshotRecoveryRequest{} },
+				func(req interface{}) interface{} { return s.SnapshotRecoveryRequest(req.(*SnapshotRecoveryRequest)) },
+			)},
+			{MethodName: "SnapshotChunk", Handler: grpcUnaryHandler(
+				func() interface{} { return &SnapshotChunkRequest{} },
+				func(req interface{}) interface{} { return s.processSnapshotChunkRequest(req.(*SnapshotChunkRequest)) },
+			)},
+			{MethodName: "SnapshotProbe", Handler: grpcUnaryHandler(
+				func() interface{} { return &SnapshotProbeRequest{} },
+				func(req interface{}) interface{} { return s.processSnapshotProbeRequest(req.(*SnapshotProbeRequest)) },
+			)},
+			{MethodName: "TimeoutNow", Handler: grpcUnaryHandler(
+				func() interface{} { return &TimeoutNowRequest{} },
+				func(req interface{}) interface{} { return s.processTimeoutNowRequest(req.(*TimeoutNowRequest)) },
+			)},
+		},
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName:    "AppendEntries",
+				Handler:       grpcAppendEntriesStreamHandler(s),
+				ClientStreams: true,
+				ServerStreams: true,
+			},
+		},
+		Metadata: "raft",
+	}, nil)
+}
+
+// grpcUnaryHandler adapts a decode-and-call pair to grpc.MethodHandler:
+// newReq allocates the concrete request type for dec to fill in, and call
+// dispatches the decoded request to the server method that answers it.
+func grpcUnaryHandler(newReq func() interface{}, call func(req interface{}) interface{}) grpc.MethodHandler {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := newReq()
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(req), nil
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: raftServiceName}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(req), nil
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+// grpcAppendEntriesStreamHandler answers the client's long-lived
+// AppendEntries stream: it reads appendEntriesStreamRequest envelopes until
+// the client closes the stream, dispatches each to s.AppendEntries, and
+// writes back an appendEntriesStreamResponse carrying the same ID so the
+// client's recvAppendEntriesLoop can match it to the right caller.
+func grpcAppendEntriesStreamHandler(s *server) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		for {
+			env := &appendEntriesStreamRequest{}
+			if err := stream.RecvMsg(env); err != nil {
+				return err
+			}
+			resp := s.AppendEntries(env.Req)
+			if err := stream.SendMsg(&appendEntriesStreamResponse{ID: env.ID, Resp: resp}); err != nil {
+				return err
+			}
+		}
+	}
+}
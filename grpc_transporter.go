@@ -0,0 +1,351 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// raftServiceName is the gRPC service name GRPCTransporter registers and
+// dials under.
+const raftServiceName = "raft.RaftService"
+
+// GRPCTransporter is a Transporter implementation built on long-lived gRPC
+// connections instead of one HTTP round trip per RPC. AppendEntries for a
+// given peer is carried over a single bidi stream so the pipelined
+// replication mode in Peer can have many requests in flight without paying
+// a new-connection cost per RPC.
+type GRPCTransporter struct {
+	// DialTimeout is the deadline for each RPC sent to a peer, including a
+	// SendAppendEntriesRequest over the shared stream. It does not bound
+	// grpc.Dial, which returns without waiting for the connection.
+	DialTimeout time.Duration
+
+	// TLSConfig, when set, is used to dial peers with transport security.
+	// Leave nil to dial in plaintext (e.g. for tests).
+	TLSConfig credentials.TransportCredentials
+
+	// Keepalive configures the client keepalive ping interval/timeout used
+	// on each peer connection.
+	Keepalive keepalive.ClientParameters
+
+	mutex sync.Mutex
+	conns map[string]*grpcPeerConn
+}
+
+// NewGRPCTransporter creates a transporter ready to dial peers. Pass nil
+// creds to connect in plaintext.
+func NewGRPCTransporter(creds credentials.TransportCredentials) *GRPCTransporter {
+	return &GRPCTransporter{
+		DialTimeout: 3 * time.Second,
+		TLSConfig:   creds,
+		conns:       make(map[string]*grpcPeerConn),
+	}
+}
+
+// grpcPeerConn owns the dialed connection and the open AppendEntries stream
+// for one peer, per the PeerTransport contract. grpc-go forbids concurrent
+// SendMsg calls (or concurrent RecvMsg calls) on the same ClientStream from
+// multiple goroutines, so every pipelined send goes through sendMsg below
+// and every reply is read by the single recvLoop goroutine and dispatched
+// to its caller by request ID via pending.
+type grpcPeerConn struct {
+	mutex   sync.Mutex
+	conn    *grpc.ClientConn
+	stream  grpc.ClientStream
+	nextID  uint64
+	pending map[uint64]chan *AppendEntriesResponse
+}
+
+// appendEntriesStreamRequest wraps a pipelined AppendEntries request with a
+// request ID so its reply can be matched up on the shared stream.
+type appendEntriesStreamRequest struct {
+	ID  uint64
+	Req *AppendEntriesRequest
+}
+
+// appendEntriesStreamResponse wraps the reply to an
+// appendEntriesStreamRequest. The server-side stream handler echoes ID back
+// unchanged.
+type appendEntriesStreamResponse struct {
+	ID   uint64
+	Resp *AppendEntriesResponse
+}
+
+// sendMsg serializes access to stream.SendMsg across every goroutine
+// pipelining requests to this peer.
+func (pc *grpcPeerConn) sendMsg(v interface{}) error {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	if pc.stream == nil {
+		return fmt.Errorf("raft.GRPCTransporter: stream closed")
+	}
+	return pc.stream.SendMsg(v)
+}
+
+func (t *GRPCTransporter) connFor(p *Peer) (*grpcPeerConn, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if pc, ok := t.conns[p.ConnectionString]; ok {
+		return pc, nil
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(gobCodec{})),
+		grpc.WithKeepaliveParams(t.Keepalive),
+	}
+	if t.TLSConfig != nil {
+		opts = append(opts, grpc.WithTransportCredentials(t.TLSConfig))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	conn, err := grpc.Dial(p.ConnectionString, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("raft.GRPCTransporter: dial %s: %v", p.ConnectionString, err)
+	}
+
+	pc := &grpcPeerConn{conn: conn}
+	t.conns[p.ConnectionString] = pc
+	return pc, nil
+}
+
+// invoke performs a single request/response RPC over the peer's connection.
+func (t *GRPCTransporter) invoke(p *Peer, method string, req interface{}, resp interface{}) error {
+	pc, err := t.connFor(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.DialTimeout)
+	defer cancel()
+	return pc.conn.Invoke(ctx, fmt.Sprintf("/%s/%s", raftServiceName, method), req, resp)
+}
+
+// ensureAppendEntriesStream opens the peer's long-lived AppendEntries
+// stream on first use so subsequent pipelined sends reuse it.
+func (t *GRPCTransporter) ensureAppendEntriesStream(p *Peer) (grpc.ClientStream, error) {
+	pc, err := t.connFor(p)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	if pc.stream != nil {
+		return pc.stream, nil
+	}
+
+	desc := &grpc.StreamDesc{StreamName: "AppendEntries", ClientStreams: true, ServerStreams: true}
+	stream, err := pc.conn.NewStream(context.Background(), desc, fmt.Sprintf("/%s/AppendEntries", raftServiceName))
+	if err != nil {
+		return nil, err
+	}
+	pc.stream = stream
+	pc.pending = make(map[uint64]chan *AppendEntriesResponse)
+	go t.recvAppendEntriesLoop(p, pc, stream)
+	return stream, nil
+}
+
+// recvAppendEntriesLoop is the single reader for a peer's AppendEntries
+// stream. It owns every RecvMsg call for the stream and hands each reply to
+// the goroutine waiting on its request ID, so concurrently pipelined sends
+// never race on the read side.
+func (t *GRPCTransporter) recvAppendEntriesLoop(p *Peer, pc *grpcPeerConn, stream grpc.ClientStream) {
+	for {
+		env := &appendEntriesStreamResponse{}
+		if err := stream.RecvMsg(env); err != nil {
+			debugln("grpc.peer.append.recv.failed: ", p.Name, err)
+			pc.mutex.Lock()
+			if pc.stream == stream {
+				pc.stream = nil
+			}
+			pending := pc.pending
+			pc.pending = nil
+			pc.mutex.Unlock()
+			for _, ch := range pending {
+				close(ch)
+			}
+			return
+		}
+
+		pc.mutex.Lock()
+		ch, ok := pc.pending[env.ID]
+		if ok {
+			delete(pc.pending, env.ID)
+		}
+		pc.mutex.Unlock()
+		if ok {
+			ch <- env.Resp
+		}
+	}
+}
+
+// SendAppendEntriesRequest sends req over the peer's shared AppendEntries
+// stream via PeerTransport, the same path pipelined replication uses, so
+// there's only one place that has to get the stream/pending-map race
+// right.
+func (t *GRPCTransporter) SendAppendEntriesRequest(s Server, p *Peer, req *AppendEntriesRequest) *AppendEntriesResponse {
+	transport, err := t.PeerTransport(p)
+	if err != nil {
+		debugln("grpc.peer.append.dial.failed: ", p.Name, err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.DialTimeout)
+	defer cancel()
+	respIface, err := transport.Send(ctx, req)
+	if err != nil {
+		debugln("grpc.peer.append.send.failed: ", p.Name, err)
+		return nil
+	}
+	resp, _ := respIface.(*AppendEntriesResponse)
+	return resp
+}
+
+func (t *GRPCTransporter) SendPreVoteRequest(s Server, p *Peer, req *PreVoteRequest) *PreVoteResponse {
+	resp := &PreVoteResponse{}
+	if err := t.invoke(p, "PreVote", req, resp); err != nil {
+		debugln("grpc.peer.prevote.failed: ", p.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *GRPCTransporter) SendVoteRequest(s Server, p *Peer, req *RequestVoteRequest) *RequestVoteResponse {
+	resp := &RequestVoteResponse{}
+	if err := t.invoke(p, "RequestVote", req, resp); err != nil {
+		debugln("grpc.peer.vote.failed: ", p.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *GRPCTransporter) SendSnapshotRequest(s Server, p *Peer, req *SnapshotRequest) *SnapshotResponse {
+	resp := &SnapshotResponse{}
+	if err := t.invoke(p, "Snapshot", req, resp); err != nil {
+		debugln("grpc.peer.snap.failed: ", p.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *GRPCTransporter) SendSnapshotRecoveryRequest(s Server, p *Peer, req *SnapshotRecoveryRequest) *SnapshotRecoveryResponse {
+	resp := &SnapshotRecoveryResponse{}
+	if err := t.invoke(p, "SnapshotRecovery", req, resp); err != nil {
+		debugln("grpc.peer.snap.recovery.failed: ", p.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *GRPCTransporter) SendSnapshotChunkRequest(s Server, p *Peer, req *SnapshotChunkRequest) *SnapshotChunkResponse {
+	resp := &SnapshotChunkResponse{}
+	if err := t.invoke(p, "SnapshotChunk", req, resp); err != nil {
+		debugln("grpc.peer.snap.chunk.failed: ", p.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *GRPCTransporter) SendSnapshotProbeRequest(s Server, p *Peer, req *SnapshotProbeRequest) *SnapshotProbeResponse {
+	resp := &SnapshotProbeResponse{}
+	if err := t.invoke(p, "SnapshotProbe", req, resp); err != nil {
+		debugln("grpc.peer.snap.probe.failed: ", p.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *GRPCTransporter) SendTimeoutNowRequest(s Server, p *Peer, req *TimeoutNowRequest) *TimeoutNowResponse {
+	resp := &TimeoutNowResponse{}
+	if err := t.invoke(p, "TimeoutNow", req, resp); err != nil {
+		debugln("grpc.peer.timeoutnow.failed: ", p.Name, err)
+		return nil
+	}
+	return resp
+}
+
+// PeerTransport opens (or reuses) the peer's long-lived AppendEntries
+// stream and returns a PeerTransport multiplexing requests over it by ID,
+// the same way SendAppendEntriesRequest does. The returned value only
+// carries *AppendEntriesRequest; it's for pipelined replication, the one
+// caller that needs this instead of a plain Send* call.
+func (t *GRPCTransporter) PeerTransport(p *Peer) (PeerTransport, error) {
+	if _, err := t.ensureAppendEntriesStream(p); err != nil {
+		return nil, err
+	}
+	pc, err := t.connFor(p)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcPeerTransport{t: t, p: p, pc: pc}, nil
+}
+
+// grpcPeerTransport adapts a peer's shared AppendEntries stream to the
+// PeerTransport interface.
+type grpcPeerTransport struct {
+	t  *GRPCTransporter
+	p  *Peer
+	pc *grpcPeerConn
+}
+
+func (g *grpcPeerTransport) Send(ctx context.Context, req interface{}) (interface{}, error) {
+	aeReq, ok := req.(*AppendEntriesRequest)
+	if !ok {
+		return nil, fmt.Errorf("raft.GRPCTransporter: PeerTransport only carries *AppendEntriesRequest, got %T", req)
+	}
+
+	g.pc.mutex.Lock()
+	if g.pc.pending == nil {
+		g.pc.mutex.Unlock()
+		return nil, fmt.Errorf("raft.GRPCTransporter: stream closed")
+	}
+	id := g.pc.nextID
+	g.pc.nextID++
+	respCh := make(chan *AppendEntriesResponse, 1)
+	g.pc.pending[id] = respCh
+	g.pc.mutex.Unlock()
+
+	if err := g.pc.sendMsg(&appendEntriesStreamRequest{ID: id, Req: aeReq}); err != nil {
+		g.pc.mutex.Lock()
+		delete(g.pc.pending, id)
+		g.pc.mutex.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-respCh:
+		if !ok {
+			return nil, fmt.Errorf("raft.GRPCTransporter: stream closed before reply")
+		}
+		return resp, nil
+	case <-ctx.Done():
+		g.pc.mutex.Lock()
+		delete(g.pc.pending, id)
+		g.pc.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// Close is a no-op: the underlying connection is shared and owned by
+// GRPCTransporter, which tears it down from its own Close.
+func (g *grpcPeerTransport) Close() error {
+	return nil
+}
+
+// Close tears down every dialed peer connection.
+func (t *GRPCTransporter) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for name, pc := range t.conns {
+		pc.conn.Close()
+		delete(t.conns, name)
+	}
+	return nil
+}
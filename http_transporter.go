@@ -0,0 +1,268 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// HTTPTransporter is the default Transporter implementation: one HTTP
+// round trip per RPC, request and response bodies JSON-encoded. It
+// predates GRPCTransporter and remains the transport for clusters that
+// don't want a gRPC dependency.
+type HTTPTransporter struct {
+	// Transport, when set, is used for outbound requests instead of
+	// http.DefaultTransport. Tests typically override this.
+	Transport http.RoundTripper
+
+	client http.Client
+}
+
+// HTTP paths each RPC type is served on. Install registers a handler for
+// each one; the Send* methods POST to the matching path on the peer.
+const (
+	httpPrefix               = "/raft"
+	httpAppendEntriesPath    = httpPrefix + "/appendEntries"
+	httpRequestVotePath      = httpPrefix + "/requestVote"
+	httpSnapshotPath         = httpPrefix + "/snapshot"
+	httpSnapshotRecoveryPath = httpPrefix + "/snapshotRecovery"
+	httpSnapshotChunkPath    = httpPrefix + "/snapshotChunk"
+	httpSnapshotProbePath    = httpPrefix + "/snapshotProbe"
+	httpPreVotePath          = httpPrefix + "/preVote"
+	httpTimeoutNowPath       = httpPrefix + "/timeoutNow"
+)
+
+// NewHTTPTransporter creates a transporter ready to dial peers over HTTP.
+func NewHTTPTransporter() *HTTPTransporter {
+	return &HTTPTransporter{}
+}
+
+// Install registers an HTTP handler for every RPC type this transporter
+// knows how to serve, decoding the request body and dispatching it to s.
+func (t *HTTPTransporter) Install(s *server, mux *http.ServeMux) {
+	mux.HandleFunc(httpAppendEntriesPath, t.appendEntriesHandler(s))
+	mux.HandleFunc(httpRequestVotePath, t.requestVoteHandler(s))
+	mux.HandleFunc(httpSnapshotPath, t.snapshotHandler(s))
+	mux.HandleFunc(httpSnapshotRecoveryPath, t.snapshotRecoveryHandler(s))
+	mux.HandleFunc(httpPreVotePath, t.preVoteHandler(s))
+	mux.HandleFunc(httpSnapshotChunkPath, t.snapshotChunkHandler(s))
+	mux.HandleFunc(httpSnapshotProbePath, t.snapshotProbeHandler(s))
+	mux.HandleFunc(httpTimeoutNowPath, t.timeoutNowHandler(s))
+}
+
+func (t *HTTPTransporter) timeoutNowHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &TimeoutNowRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(s.processTimeoutNowRequest(req))
+	}
+}
+
+func (t *HTTPTransporter) snapshotChunkHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &SnapshotChunkRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(s.processSnapshotChunkRequest(req))
+	}
+}
+
+func (t *HTTPTransporter) snapshotProbeHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &SnapshotProbeRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(s.processSnapshotProbeRequest(req))
+	}
+}
+
+func (t *HTTPTransporter) preVoteHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &PreVoteRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(s.processPreVoteRequest(req))
+	}
+}
+
+func (t *HTTPTransporter) appendEntriesHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &AppendEntriesRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(s.AppendEntries(req))
+	}
+}
+
+func (t *HTTPTransporter) requestVoteHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &RequestVoteRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(s.RequestVote(req))
+	}
+}
+
+func (t *HTTPTransporter) snapshotHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &SnapshotRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(s.SnapshotRequest(req))
+	}
+}
+
+func (t *HTTPTransporter) snapshotRecoveryHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &SnapshotRecoveryRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(s.SnapshotRecoveryRequest(req))
+	}
+}
+
+// postJSON POSTs req as JSON to path on peer's ConnectionString and
+// decodes the response into resp. Returns an error if the round trip
+// failed or the peer responded with a non-200 status.
+func (t *HTTPTransporter) postJSON(peer *Peer, path string, req interface{}, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	client := t.client
+	if t.Transport != nil {
+		client.Transport = t.Transport
+	}
+	httpResp, err := client.Post(peer.ConnectionString+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("raft.HTTPTransporter: %s: unexpected status %d", path, httpResp.StatusCode)
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func (t *HTTPTransporter) SendAppendEntriesRequest(s Server, peer *Peer, req *AppendEntriesRequest) *AppendEntriesResponse {
+	resp := &AppendEntriesResponse{}
+	if err := t.postJSON(peer, httpAppendEntriesPath, req, resp); err != nil {
+		debugln("http.peer.append.failed: ", peer.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *HTTPTransporter) SendVoteRequest(s Server, peer *Peer, req *RequestVoteRequest) *RequestVoteResponse {
+	resp := &RequestVoteResponse{}
+	if err := t.postJSON(peer, httpRequestVotePath, req, resp); err != nil {
+		debugln("http.peer.vote.failed: ", peer.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *HTTPTransporter) SendSnapshotRequest(s Server, peer *Peer, req *SnapshotRequest) *SnapshotResponse {
+	resp := &SnapshotResponse{}
+	if err := t.postJSON(peer, httpSnapshotPath, req, resp); err != nil {
+		debugln("http.peer.snap.failed: ", peer.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *HTTPTransporter) SendSnapshotRecoveryRequest(s Server, peer *Peer, req *SnapshotRecoveryRequest) *SnapshotRecoveryResponse {
+	resp := &SnapshotRecoveryResponse{}
+	if err := t.postJSON(peer, httpSnapshotRecoveryPath, req, resp); err != nil {
+		debugln("http.peer.snap.recovery.failed: ", peer.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *HTTPTransporter) SendPreVoteRequest(s Server, peer *Peer, req *PreVoteRequest) *PreVoteResponse {
+	resp := &PreVoteResponse{}
+	if err := t.postJSON(peer, httpPreVotePath, req, resp); err != nil {
+		debugln("http.peer.prevote.failed: ", peer.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *HTTPTransporter) SendSnapshotChunkRequest(s Server, peer *Peer, req *SnapshotChunkRequest) *SnapshotChunkResponse {
+	resp := &SnapshotChunkResponse{}
+	if err := t.postJSON(peer, httpSnapshotChunkPath, req, resp); err != nil {
+		debugln("http.peer.snap.chunk.failed: ", peer.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *HTTPTransporter) SendSnapshotProbeRequest(s Server, peer *Peer, req *SnapshotProbeRequest) *SnapshotProbeResponse {
+	resp := &SnapshotProbeResponse{}
+	if err := t.postJSON(peer, httpSnapshotProbePath, req, resp); err != nil {
+		debugln("http.peer.snap.probe.failed: ", peer.Name, err)
+		return nil
+	}
+	return resp
+}
+
+func (t *HTTPTransporter) SendTimeoutNowRequest(s Server, peer *Peer, req *TimeoutNowRequest) *TimeoutNowResponse {
+	resp := &TimeoutNowResponse{}
+	if err := t.postJSON(peer, httpTimeoutNowPath, req, resp); err != nil {
+		debugln("http.peer.timeoutnow.failed: ", peer.Name, err)
+		return nil
+	}
+	return resp
+}
+
+// PeerTransport returns a PeerTransport that posts each request as its own
+// HTTP round trip. HTTP has no connection-multiplexing benefit to offer
+// pipelined replication the way GRPCTransporter's shared stream does, but
+// implementing the interface lets callers use PeerTransport uniformly
+// regardless of which Transporter a peer is configured with.
+func (t *HTTPTransporter) PeerTransport(peer *Peer) (PeerTransport, error) {
+	return &httpPeerTransport{t: t, peer: peer}, nil
+}
+
+type httpPeerTransport struct {
+	t    *HTTPTransporter
+	peer *Peer
+}
+
+func (h *httpPeerTransport) Send(ctx context.Context, req interface{}) (interface{}, error) {
+	aeReq, ok := req.(*AppendEntriesRequest)
+	if !ok {
+		return nil, fmt.Errorf("raft.HTTPTransporter: PeerTransport only carries *AppendEntriesRequest, got %T", req)
+	}
+	resp := &AppendEntriesResponse{}
+	if err := h.t.postJSON(h.peer, httpAppendEntriesPath, aeReq, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (h *httpPeerTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,35 @@
+package raft
+
+// Inflight tracks a single outstanding pipelined AppendEntries RPC so the
+// leader can reconcile prevLogIndex in order once the response arrives,
+// even though later RPCs for the same peer may already be in flight and
+// their responses may arrive out of order relative to this one.
+type Inflight struct {
+	firstIndex uint64
+	lastIndex  uint64
+	term       uint64
+
+	// resolved and resp are set once this RPC's round trip has completed;
+	// resp stays nil if no reply was received. Responses are only acted on and forwarded once they reach the head
+	// of the peer's inflight queue, in send order, even if the
+	// underlying network round trip that resolved them completed out of
+	// order.
+	resolved bool
+	resp     *AppendEntriesResponse
+}
+
+// Creates a new Inflight record for a batch of entries sent to a peer.
+func newInflight(firstIndex uint64, lastIndex uint64, term uint64) *Inflight {
+	return &Inflight{
+		firstIndex: firstIndex,
+		lastIndex:  lastIndex,
+		term:       term,
+	}
+}
+
+// succeeded reports whether this record's response confirms the batch was
+// replicated: a reply was received, it reports success, and it's still for
+// the term this batch was sent under.
+func (in *Inflight) succeeded() bool {
+	return in.resp != nil && in.resp.Success() && in.resp.Term() == in.term
+}
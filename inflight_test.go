@@ -0,0 +1,44 @@
+package raft
+
+import "testing"
+
+func TestInflightDrainResult(t *testing.T) {
+	tests := []struct {
+		name         string
+		resolved     []bool
+		succeeded    []bool
+		wantReady    int
+		wantTruncate bool
+	}{
+		{"empty queue", nil, nil, 0, false},
+		{"head unresolved", []bool{false, true}, []bool{false, true}, 0, false},
+		{"all resolved and succeeded dispatches everything", []bool{true, true, true}, []bool{true, true, true}, 3, false},
+		{"resolved run stops before an unresolved gap", []bool{true, true, false}, []bool{true, true, false}, 2, false},
+		{"a failure truncates at and including itself", []bool{true, true, true}, []bool{true, false, true}, 2, true},
+		{"a failure at the head truncates immediately", []bool{true}, []bool{false}, 1, true},
+		{"success after an unresolved record doesn't count yet", []bool{true, false, true}, []bool{true, false, true}, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, truncate := inflightDrainResult(tt.resolved, tt.succeeded)
+			if ready != tt.wantReady || truncate != tt.wantTruncate {
+				t.Errorf("inflightDrainResult(%v, %v) = (%d, %v), want (%d, %v)",
+					tt.resolved, tt.succeeded, ready, truncate, tt.wantReady, tt.wantTruncate)
+			}
+		})
+	}
+}
+
+func TestInflightSucceeded(t *testing.T) {
+	in := newInflight(5, 7, 3)
+	if in.succeeded() {
+		t.Error("succeeded() true before a response is set")
+	}
+
+	in.resp = nil
+	in.resolved = true
+	if in.succeeded() {
+		t.Error("succeeded() true with a nil response")
+	}
+}
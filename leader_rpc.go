@@ -0,0 +1,39 @@
+package raft
+
+import "fmt"
+
+// ErrNotLeader is returned by RerouteToLeader when the cluster's current
+// leader is unknown, so the caller has no connection string to retry with.
+var ErrNotLeader = fmt.Errorf("raft: no known leader to reroute to")
+
+// ErrLeadershipTransferInProgress is returned by RerouteToLeader when this
+// server is in the middle of TransferLeadership. It has stopped accepting
+// new client commands until the transfer completes or times out, so the
+// caller should retry shortly rather than have the command risk being
+// acknowledged by a leader that's about to hand off.
+var ErrLeadershipTransferInProgress = fmt.Errorf("raft: leadership transfer in progress, retry shortly")
+
+// RerouteToLeader transparently forwards a client RPC to the current
+// leader when it arrives at a follower, instead of making every client
+// track leadership itself. do is called with the leader's connection
+// string (this server's own when it is the leader).
+func RerouteToLeader(s Server, do func(connectionString string) (interface{}, error)) (interface{}, error) {
+	if s.State() == Leader {
+		if s.TransferringLeadership() {
+			return nil, ErrLeadershipTransferInProgress
+		}
+		return do(s.ConnectionString())
+	}
+
+	leaderName := s.Leader()
+	if leaderName == "" {
+		return nil, ErrNotLeader
+	}
+
+	peer := s.Peers()[leaderName]
+	if peer == nil {
+		return nil, ErrNotLeader
+	}
+
+	return do(peer.ConnectionString)
+}
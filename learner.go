@@ -0,0 +1,106 @@
+package raft
+
+import "fmt"
+
+// DefaultLearnerPromotionLagThreshold is how close, in log entries, a
+// learner's replicated index must be to the leader's current index before
+// LearnerPromotedEventType fires.
+const DefaultLearnerPromotionLagThreshold = 10
+
+// AddLearner adds name as a non-voting peer: it receives heartbeats and log
+// replication like any other peer so it can catch up, but doesn't count
+// towards quorum or election votes until PromoteLearner is called for it.
+//
+// Exclusion from quorum here only covers votingPeers(), which gates
+// elections and ReadIndex/LeaseRead confirmation. Whatever determines log
+// commit-index advancement lives outside the files in this package slice
+// and isn't reachable from here, so a learner that hasn't been promoted yet
+// may still be counted there; that gap needs fixing in whatever owns commit
+// index advancement, not in this file.
+func (s *server) AddLearner(name string, connectionString string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.peers[name]; ok {
+		return fmt.Errorf("raft.Server: peer already exists: %s", name)
+	}
+
+	peer := newPeer(s, name, connectionString, s.heartbeatInterval)
+	peer.IsLearner = true
+	if s.State() == Leader {
+		peer.setPrevLogIndex(s.log.currentIndex())
+		peer.startHeartbeat()
+	}
+	s.peers[name] = peer
+
+	return nil
+}
+
+// PromoteLearner turns an existing learner into a full voting peer by
+// committing a joint-consensus config change command through the log, the
+// same path used for normal membership changes. Once that command is
+// applied, the peer's IsLearner flag is cleared and it starts counting
+// towards quorum and election votes.
+func (s *server) PromoteLearner(name string) error {
+	s.mutex.RLock()
+	peer, ok := s.peers[name]
+	s.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("raft.Server: cannot promote unknown peer: %s", name)
+	}
+	if !peer.IsLearner {
+		return fmt.Errorf("raft.Server: peer %s is not a learner", name)
+	}
+
+	_, err := s.Do(&LearnerPromotionCommand{Name: name})
+	return err
+}
+
+// checkLearnerPromotionReady dispatches LearnerPromotedEventType the first
+// time a learner's replicated index comes within
+// DefaultLearnerPromotionLagThreshold entries of the leader's current log
+// index, and not again until it falls back out of that threshold. Called
+// after each successful AppendEntries response is processed for a learner
+// peer, which is every heartbeat once the learner is caught up, so without
+// the already-notified guard this would fire once per heartbeat forever.
+func (s *server) checkLearnerPromotionReady(peer *Peer) {
+	if !peer.IsLearner {
+		return
+	}
+
+	lag := s.log.currentIndex() - peer.getPrevLogIndex()
+	if lag > DefaultLearnerPromotionLagThreshold {
+		peer.clearPromotionNotified()
+		return
+	}
+
+	if !peer.checkAndSetPromotionNotified() {
+		s.DispatchEvent(newEvent(LearnerPromotedEventType, peer, nil))
+	}
+}
+
+// LearnerPromotionCommand is the joint-consensus config change command
+// committed through the log when a learner is promoted to a voting peer,
+// following the same shape as the server's other membership-change
+// commands.
+type LearnerPromotionCommand struct {
+	Name string `json:"name"`
+}
+
+// CommandName returns the name this command is registered under.
+func (c *LearnerPromotionCommand) CommandName() string {
+	return "raft:learnerPromotion"
+}
+
+// Apply clears the IsLearner flag for the named peer once the config
+// change has been committed by a quorum of voting peers. It runs on every
+// server that applies the command, not just the one that initiated
+// PromoteLearner, so the whole cluster converges on the same voting set.
+func (c *LearnerPromotionCommand) Apply(server Server) (interface{}, error) {
+	peer, ok := server.Peers()[c.Name]
+	if !ok {
+		return nil, fmt.Errorf("raft.Server: cannot apply learner promotion: unknown peer %s", c.Name)
+	}
+	peer.Promote()
+	return nil, nil
+}
@@ -0,0 +1,25 @@
+package raft
+
+import "testing"
+
+func TestFilterVotingPeers(t *testing.T) {
+	peers := map[string]*Peer{
+		"voter-a":   {Name: "voter-a"},
+		"voter-b":   {Name: "voter-b"},
+		"learner-a": {Name: "learner-a", IsLearner: true},
+	}
+
+	voters := filterVotingPeers(peers)
+
+	if len(voters) != 2 {
+		t.Fatalf("filterVotingPeers returned %d peers, want 2: %v", len(voters), voters)
+	}
+	if _, ok := voters["learner-a"]; ok {
+		t.Errorf("filterVotingPeers included learner-a, a learner")
+	}
+	for _, name := range []string{"voter-a", "voter-b"} {
+		if _, ok := voters[name]; !ok {
+			t.Errorf("filterVotingPeers dropped voting peer %s", name)
+		}
+	}
+}
@@ -1,11 +1,25 @@
 package raft
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"sync"
 	"time"
 )
 
+//------------------------------------------------------------------------------
+//
+// Constants
+//
+//------------------------------------------------------------------------------
+
+// DefaultMaxPipelinedInflight is a window size for SetMaxPipelinedInflight:
+// the number of AppendEntries RPCs a peer may have outstanding at once. It
+// is not applied automatically; peers start with pipelining disabled.
+const DefaultMaxPipelinedInflight = 8
+
 //------------------------------------------------------------------------------
 //
 // Typedefs
@@ -14,15 +28,42 @@ import (
 
 // A peer is a reference to another server involved in the consensus protocol.
 type Peer struct {
-	server            *server
-	Name              string `json:"name"`
-	ConnectionString  string `json:"connectionString"`
-	prevLogIndex      uint64
+	server           *server
+	Name             string `json:"name"`
+	ConnectionString string `json:"connectionString"`
+	// IsLearner marks the peer as non-voting: it receives heartbeats and
+	// replication like any other peer so it can catch up, but is excluded
+	// from quorum and election vote counting until Promote()d.
+	IsLearner    bool `json:"isLearner"`
+	prevLogIndex uint64
+
+	// confirmedIndex is the highest log index this peer has acknowledged
+	// replicating, advanced only once a response confirms it, never
+	// optimistically like prevLogIndex is under pipelining. isCaughtUp
+	// checks this, not prevLogIndex, so TransferLeadership can't send
+	// TimeoutNowRequest to a peer whose last pipelined batch is still
+	// unacknowledged.
+	confirmedIndex uint64
+
 	mutex             sync.RWMutex
 	stopChan          chan bool
 	heartbeatInterval time.Duration
 	heartbeatTicker   <-chan time.Time
 	failedHeartbeats  float64
+	lastContact       time.Time
+
+	// Pipelining allows up to maxPipelinedInflight AppendEntries RPCs to be
+	// outstanding at once instead of waiting for each response in turn.
+	pipelining           bool
+	maxPipelinedInflight int
+	inflightSem          chan struct{}
+	inflight             []*Inflight
+
+	// promotionNotified tracks whether checkLearnerPromotionReady has
+	// already dispatched LearnerPromotedEventType for this learner, so it
+	// fires once per promotion-readiness window instead of on every
+	// successful AppendEntries response.
+	promotionNotified bool
 }
 
 //------------------------------------------------------------------------------
@@ -52,6 +93,39 @@ func (p *Peer) setHeartbeatInterval(duration time.Duration) {
 	p.heartbeatInterval = duration
 }
 
+//--------------------------------------
+// Pipelining
+//--------------------------------------
+
+// Sets the maximum number of AppendEntries RPCs this peer may have in
+// flight at once and enables pipelined replication. A value <= 1 disables
+// pipelining and falls back to the lock-step mode.
+func (p *Peer) setMaxPipelinedInflight(n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.maxPipelinedInflight = n
+	p.pipelining = n > 1
+	size := n
+	if size < 1 {
+		size = 1
+	}
+	p.inflightSem = make(chan struct{}, size)
+	p.inflight = nil
+}
+
+// SetMaxPipelinedInflight turns on pipelined AppendEntries replication for
+// every current peer, allowing up to n requests in flight per peer at
+// once. A value <= 1 disables pipelining and falls back to the lock-step
+// mode. Peers added after this call are not affected: they start in
+// lock-step mode until it's called again.
+func (s *server) SetMaxPipelinedInflight(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, peer := range s.peers {
+		peer.setMaxPipelinedInflight(n)
+	}
+}
+
 //--------------------------------------
 // Prev log index
 //--------------------------------------
@@ -70,6 +144,53 @@ func (p *Peer) setPrevLogIndex(value uint64) {
 	p.prevLogIndex = value
 }
 
+// getConfirmedIndex retrieves the highest log index this peer has
+// confirmed replicating. See the confirmedIndex field doc for why this,
+// not prevLogIndex, is what isCaughtUp checks.
+func (p *Peer) getConfirmedIndex() uint64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.confirmedIndex
+}
+
+//--------------------------------------
+// Learner promotion readiness
+//--------------------------------------
+
+// checkAndSetPromotionNotified reports whether promotionNotified was already
+// set, and sets it. checkLearnerPromotionReady uses this to dispatch
+// LearnerPromotedEventType only the first time a learner comes within
+// DefaultLearnerPromotionLagThreshold, not on every subsequent heartbeat.
+func (p *Peer) checkAndSetPromotionNotified() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	already := p.promotionNotified
+	p.promotionNotified = true
+	return already
+}
+
+// clearPromotionNotified resets promotionNotified so a learner that falls
+// back out of the lag threshold (e.g. after a slow follower drops behind
+// again) gets a fresh notification once it catches back up.
+func (p *Peer) clearPromotionNotified() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.promotionNotified = false
+}
+
+//--------------------------------------
+// Last contact
+//--------------------------------------
+
+// Retrieves the time of the last successful AppendEntries response from
+// this peer, used by the leader to decide whether a LeaseRead can skip the
+// heartbeat round.
+func (p *Peer) getLastContact() time.Time {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.lastContact
+}
+
 //------------------------------------------------------------------------------
 //
 // Methods
@@ -123,10 +244,25 @@ func (p *Peer) clone() *Peer {
 	return &Peer{
 		Name:             p.Name,
 		ConnectionString: p.ConnectionString,
+		IsLearner:        p.IsLearner,
 		prevLogIndex:     p.prevLogIndex,
 	}
 }
 
+//--------------------------------------
+// Learner
+//--------------------------------------
+
+// Promote turns a learner into a full, voting peer. Callers are expected to
+// do this via Server.PromoteLearner, which also emits the joint-consensus
+// config change command; Promote itself just flips the local flag once
+// that command has been committed.
+func (p *Peer) Promote() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.IsLearner = false
+}
+
 //--------------------------------------
 // Heartbeat
 //--------------------------------------
@@ -172,7 +308,12 @@ func (p *Peer) flush() {
 	entries, prevLogTerm := p.server.log.getEntriesAfter(prevLogIndex, p.server.maxLogEntriesPerRequest)
 
 	if entries != nil {
-		p.sendAppendEntriesRequest(newAppendEntriesRequest(term, prevLogIndex, prevLogTerm, p.server.log.CommitIndex(), p.server.name, entries))
+		req := newAppendEntriesRequest(term, prevLogIndex, prevLogTerm, p.server.log.CommitIndex(), p.server.name, entries)
+		if p.pipelining {
+			p.sendAppendEntriesRequestPipelined(req)
+		} else {
+			p.sendAppendEntriesRequest(req)
+		}
 	} else {
 		p.sendSnapshotRequest(newSnapshotRequest(p.server.name, p.server.snapshot))
 	}
@@ -204,8 +345,10 @@ func (p *Peer) sendAppendEntriesRequest(req *AppendEntriesRequest) {
 	// If successful then update the previous log index.
 	p.mutex.Lock()
 	if resp.Success() {
+		p.lastContact = time.Now()
 		if len(req.Entries) > 0 {
 			p.prevLogIndex = req.Entries[len(req.Entries)-1].GetIndex()
+			p.confirmedIndex = p.prevLogIndex
 
 			// if peer append a log entry from the current term
 			// we set append to true
@@ -250,12 +393,170 @@ func (p *Peer) sendAppendEntriesRequest(req *AppendEntriesRequest) {
 	}
 	p.mutex.Unlock()
 
+	if resp.Success() {
+		p.server.checkLearnerPromotionReady(p)
+	}
+
 	// Attach the peer to resp, thus server can know where it comes from
 	resp.peer = p.Name
 	// Send response to server for processing.
 	p.server.sendAsync(resp)
 }
 
+// Sends an AppendEntries request without waiting for the previous one to
+// complete. Up to maxPipelinedInflight requests may be outstanding at once;
+// sendAppendEntriesResponsePipelined reconciles responses as they arrive, in
+// the order the requests were sent. prevLogIndex is advanced optimistically
+// to the batch's last entry as soon as it is sent; confirmedIndex is never
+// advanced past an un-acked entry. Any failure or term change disables pipelining for the
+// peer and falls back to the lock-step path on the next flush.
+func (p *Peer) sendAppendEntriesRequestPipelined(req *AppendEntriesRequest) {
+	if len(req.Entries) == 0 {
+		p.sendAppendEntriesRequest(req)
+		return
+	}
+
+	p.inflightSem <- struct{}{}
+
+	p.mutex.Lock()
+	inflight := newInflight(req.Entries[0].GetIndex(), req.Entries[len(req.Entries)-1].GetIndex(), req.Term)
+	p.inflight = append(p.inflight, inflight)
+	p.prevLogIndex = inflight.lastIndex
+	p.mutex.Unlock()
+
+	go func() {
+		defer func() { <-p.inflightSem }()
+
+		resp := p.sendPipelinedViaPeerTransport(req)
+		p.server.DispatchEvent(newEvent(PipelineFlushEventType, req, nil))
+		p.sendAppendEntriesResponsePipelined(req, inflight, resp)
+	}()
+}
+
+// sendPipelinedViaPeerTransport sends a pipelined AppendEntries request
+// through the peer's PeerTransport rather than a plain Transporter.Send*
+// call, so a transport that multiplexes requests over a single long-lived
+// connection per peer (GRPCTransporter) can do so instead of paying a
+// new-connection cost for every pipelined batch.
+func (p *Peer) sendPipelinedViaPeerTransport(req *AppendEntriesRequest) *AppendEntriesResponse {
+	transport, err := p.server.Transporter().PeerTransport(p)
+	if err != nil {
+		debugln("peer.append.pipeline.transport.failed: ", p.Name, err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.server.ElectionTimeout())
+	defer cancel()
+	respIface, err := transport.Send(ctx, req)
+	if err != nil {
+		debugln("peer.append.pipeline.send.failed: ", p.Name, err)
+		return nil
+	}
+	resp, _ := respIface.(*AppendEntriesResponse)
+	return resp
+}
+
+// inflightDrainResult reports how many leading records of a peer's inflight
+// queue are ready to dispatch, in send order, and whether a failure among
+// them means the rest of the queue must be discarded rather than waiting
+// its turn for a still-outstanding response. resolved[i]/succeeded[i]
+// describe the record at queue position i; succeeded[i] is false for a
+// record that is not resolved, and records after the first unresolved one
+// are not examined, whatever their flags say.
+//
+// This depends only on that bookkeeping, not on Peer or *server, so it's
+// covered directly in inflight_test.go without needing a working server to
+// construct one.
+func inflightDrainResult(resolved []bool, succeeded []bool) (ready int, truncate bool) {
+	for i := range resolved {
+		if !resolved[i] {
+			break
+		}
+		ready++
+		if !succeeded[i] {
+			truncate = true
+			break
+		}
+	}
+	return ready, truncate
+}
+
+// Reconciles the response to a pipelined AppendEntries RPC. A response may
+// arrive out of order relative to others still outstanding for this peer,
+// since each is an independent network round trip: this marks inflight
+// resolved in place, then dispatches every already-resolved record
+// starting at the head of the queue, in the order their requests were
+// sent, so confirmedIndex and server.sendAsync both still see responses in
+// send order. A response either dispatches now or waits, marked resolved,
+// for the records ahead of it to do so first, unless an earlier failure
+// has truncated the queue: responses for discarded records are dropped.
+func (p *Peer) sendAppendEntriesResponsePipelined(req *AppendEntriesRequest, inflight *Inflight, resp *AppendEntriesResponse) {
+	p.mutex.Lock()
+
+	pending := false
+	for _, in := range p.inflight {
+		if in == inflight {
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		// An earlier, already-dispatched record in this pipeline failed
+		// and disabled pipelining, clearing the queue; there's nothing
+		// left to reconcile this response against.
+		p.mutex.Unlock()
+		return
+	}
+	inflight.resolved = true
+	inflight.resp = resp
+
+	resolved := make([]bool, len(p.inflight))
+	succeeded := make([]bool, len(p.inflight))
+	for i, in := range p.inflight {
+		resolved[i] = in.resolved
+		succeeded[i] = in.succeeded()
+	}
+	n, truncate := inflightDrainResult(resolved, succeeded)
+
+	ready := append([]*Inflight(nil), p.inflight[:n]...)
+	p.inflight = p.inflight[n:]
+
+	for _, head := range ready {
+		if head.succeeded() {
+			p.lastContact = time.Now()
+			p.confirmedIndex = head.lastIndex
+		}
+	}
+
+	if truncate {
+		// Fall back to lock-step: stop acting on anything still
+		// outstanding for this peer and let the next heartbeat tick
+		// resolve prevLogIndex the slow way. Don't leave prevLogIndex at
+		// the optimistic high-water mark the failed batch was sent
+		// with: walk it back to just before that batch's first entry.
+		failed := ready[len(ready)-1]
+		p.pipelining = false
+		if failed.firstIndex > 0 && p.prevLogIndex >= failed.firstIndex {
+			p.prevLogIndex = failed.firstIndex - 1
+		}
+		p.inflight = nil
+	}
+	p.mutex.Unlock()
+
+	for _, head := range ready {
+		switch {
+		case head.succeeded():
+			p.server.checkLearnerPromotionReady(p)
+		case head.resp == nil:
+			p.backoffHeartbeat()
+		}
+		if head.resp != nil {
+			head.resp.peer = p.Name
+			p.server.sendAsync(head.resp)
+		}
+	}
+}
+
 // Sends an Snapshot request to the peer through the transport.
 func (p *Peer) sendSnapshotRequest(req *SnapshotRequest) {
 	debugln("peer.snap.send: ", p.Name)
@@ -279,25 +580,67 @@ func (p *Peer) sendSnapshotRequest(req *SnapshotRequest) {
 
 }
 
-// Sends an Snapshot Recovery request to the peer through the transport.
+// Sends an Snapshot Recovery request to the peer through the transport,
+// streaming the state in fixed-size chunks so multi-GB snapshots don't have
+// to fit in a single RPC. A transfer interrupted partway through is resumed
+// on the next attempt by probing the follower for how much it already has.
 func (p *Peer) sendSnapshotRecoveryRequest() {
-	req := newSnapshotRecoveryRequest(p.server.name, p.server.snapshot)
-	debugln("peer.snap.recovery.send: ", p.Name)
-	resp := p.server.Transporter().SendSnapshotRecoveryRequest(p.server, p, req)
+	snapshot := p.server.snapshot
+	data := snapshot.State
+	snapshotID := fmt.Sprintf("%s-%d", p.server.name, snapshot.LastIndex)
+
+	offset, err := p.probeSnapshotOffset(snapshotID)
+	if err != nil {
+		debugln("peer.snap.recovery.probe.failed: ", p.Name, err)
+		offset = 0
+	}
 
-	if resp == nil {
-		debugln("peer.snap.recovery.timeout: ", p.Name)
-		return
+	if sem := p.inflightSem; sem != nil {
+		sem <- struct{}{}
+		defer func() { <-sem }()
 	}
 
-	if resp.Success {
-		p.prevLogIndex = req.LastIndex
-	} else {
-		debugln("peer.snap.recovery.failed: ", p.Name)
-		return
+	for {
+		end := offset + int64(DefaultSnapshotChunkSize)
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		done := end == int64(len(data))
+
+		chunk := newSnapshotChunkRequest(p.server.name, snapshotID, offset, done, data[offset:end])
+		debugln("peer.snap.recovery.chunk.send: ", p.Name, " offset=", offset, " done=", done)
+
+		resp := p.server.Transporter().SendSnapshotChunkRequest(p.server, p, chunk)
+		p.server.DispatchEvent(newEvent(SnapshotChunkEventType, chunk, nil))
+
+		if resp == nil {
+			debugln("peer.snap.recovery.chunk.timeout: ", p.Name)
+			return
+		}
+		if !resp.Success {
+			debugln("peer.snap.recovery.chunk.failed: ", p.Name)
+			return
+		}
+
+		offset = resp.Offset
+		if done {
+			break
+		}
 	}
 
-	p.server.sendAsync(resp)
+	p.prevLogIndex = snapshot.LastIndex
+}
+
+// Probes the follower for the byte offset it has already received for a
+// given snapshot transfer, so an interrupted transfer can resume instead of
+// restarting from scratch. Returns offset 0 if the follower has nothing.
+func (p *Peer) probeSnapshotOffset(snapshotID string) (int64, error) {
+	probe := newSnapshotProbeRequest(p.server.name, snapshotID)
+	resp := p.server.Transporter().SendSnapshotProbeRequest(p.server, p, probe)
+	if resp == nil {
+		return 0, errors.New("raft.Peer: snapshot probe request failed")
+	}
+	return resp.ReceivedOffset, nil
 }
 
 //--------------------------------------
@@ -316,3 +659,50 @@ func (p *Peer) sendVoteRequest(req *RequestVoteRequest, c chan *RequestVoteRespo
 		debugln("peer.vote.failed: ", p.server.Name(), "<-", p.Name)
 	}
 }
+
+// Sends a PreVoteRequest to the peer. Unlike sendVoteRequest, the receiver
+// does not persist any state or bump its term to answer this, so a
+// candidate can use the replies to gauge whether it would actually win an
+// election before paying the cost of incrementing its term and disrupting
+// the current leader.
+func (p *Peer) sendPreVoteRequest(req *PreVoteRequest, c chan *PreVoteResponse) {
+	debugln("peer.prevote: ", p.server.Name(), "->", p.Name)
+	req.peer = p
+	if resp := p.server.Transporter().SendPreVoteRequest(p.server, p, req); resp != nil {
+		debugln("peer.prevote.recv: ", p.server.Name(), "<-", p.Name)
+		resp.peer = p
+		c <- resp
+	} else {
+		debugln("peer.prevote.failed: ", p.server.Name(), "<-", p.Name)
+	}
+}
+
+//--------------------------------------
+// Leadership Transfer
+//--------------------------------------
+
+// Sends a TimeoutNowRequest to the peer, telling it to start an election
+// for term+1 right away instead of waiting out its election timer. Used by
+// Server.TransferLeadership once the peer's log is fully caught up.
+func (p *Peer) sendTimeoutNowRequest(req *TimeoutNowRequest) *TimeoutNowResponse {
+	debugln("peer.timeoutnow: ", p.server.Name(), "->", p.Name)
+	req.peer = p
+	resp := p.server.Transporter().SendTimeoutNowRequest(p.server, p, req)
+	if resp == nil {
+		debugln("peer.timeoutnow.failed: ", p.server.Name(), "<-", p.Name)
+		return nil
+	}
+	debugln("peer.timeoutnow.recv: ", p.server.Name(), "<-", p.Name)
+	resp.peer = p
+	return resp
+}
+
+// isCaughtUp reports whether this peer's log is fully replicated through
+// the leader's current log index, the precondition TransferLeadership waits
+// on before sending TimeoutNowRequest. This checks confirmedIndex rather
+// than prevLogIndex: under pipelining, prevLogIndex is bumped optimistically
+// as soon as a batch is sent, before its response is known, so it can reach
+// the leader's current index while the last batch is still unacknowledged.
+func (p *Peer) isCaughtUp() bool {
+	return p.getConfirmedIndex() == p.server.log.currentIndex()
+}
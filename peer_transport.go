@@ -0,0 +1,16 @@
+package raft
+
+import "context"
+
+// PeerTransport owns the connection lifecycle for a single peer and sends
+// requests over it without assuming strict request/response RPC semantics,
+// so implementations such as GRPCTransporter can multiplex requests over a
+// single long-lived stream per peer.
+type PeerTransport interface {
+	// Send issues req to the peer and returns its response, or an error if
+	// the request could not be delivered or the peer did not reply.
+	Send(ctx context.Context, req interface{}) (interface{}, error)
+
+	// Close tears down the underlying connection.
+	Close() error
+}
@@ -0,0 +1,23 @@
+package raft
+
+// PreVoteRequest has the same shape as RequestVoteRequest, but a receiver
+// must not persist any state or increment its term when handling one: it
+// only reports whether it *would* grant a real vote right now.
+type PreVoteRequest struct {
+	Term          uint64
+	LastLogIndex  uint64
+	LastLogTerm   uint64
+	CandidateName string
+
+	peer *Peer
+}
+
+// Creates a new PreVoteRequest.
+func newPreVoteRequest(term uint64, candidateName string, lastLogIndex uint64, lastLogTerm uint64) *PreVoteRequest {
+	return &PreVoteRequest{
+		Term:          term,
+		CandidateName: candidateName,
+		LastLogIndex:  lastLogIndex,
+		LastLogTerm:   lastLogTerm,
+	}
+}
@@ -0,0 +1,20 @@
+package raft
+
+// PreVoteResponse reports whether the responder would grant a real vote for
+// the term in the matching PreVoteRequest. It carries the responder's
+// current term so a candidate that is behind can learn about it without
+// having bumped its own term to find out.
+type PreVoteResponse struct {
+	Term        uint64
+	VoteGranted bool
+
+	peer *Peer
+}
+
+// Creates a new PreVoteResponse.
+func newPreVoteResponse(term uint64, voteGranted bool) *PreVoteResponse {
+	return &PreVoteResponse{
+		Term:        term,
+		VoteGranted: voteGranted,
+	}
+}
@@ -0,0 +1,145 @@
+package raft
+
+import (
+	"errors"
+	"time"
+)
+
+// ReadMode selects how Server.ReadIndex confirms the leader is still
+// leader before returning a read index.
+type ReadMode int
+
+const (
+	// ReadIndexMode confirms leadership with a round of heartbeats to a
+	// majority of peers before every read. Always safe.
+	ReadIndexMode ReadMode = iota
+
+	// LeaseReadMode skips the heartbeat round as long as every peer in the
+	// quorum has been heard from within electionTimeout - clockDriftBound,
+	// trading a small dependency on clock drift bounds for lower read
+	// latency.
+	LeaseReadMode
+)
+
+// errLeaseExpired is returned internally when LeaseReadMode can't confirm
+// leadership from existing peer contact timestamps and must fall back to
+// a heartbeat round.
+var errLeaseExpired = errors.New("raft: lease expired, falling back to heartbeat round")
+
+// clockDriftBound is subtracted from the election timeout when deciding if
+// a lease read is still safe, to account for clock drift between nodes.
+const clockDriftBound = 50 * time.Millisecond
+
+// readIndexWaiter is a pending ReadIndex call waiting on the outcome of the
+// next heartbeat round. Concurrent callers are batched onto a single round
+// so N concurrent reads cost one round of heartbeats, not N.
+type readIndexWaiter struct {
+	index uint64
+	errc  chan error
+}
+
+// ReadIndex implements the ReadIndex protocol for linearizable reads
+// without going through the log: it snapshots commitIndex, confirms via a
+// round of heartbeats (or, in LeaseReadMode, via recent peer contact) that
+// this server is still leader, and returns the index the caller should wait
+// for the local state machine to apply before serving the read.
+func (s *server) ReadIndex() (uint64, error) {
+	if s.State() != Leader {
+		return 0, ErrNotLeader
+	}
+
+	index := s.log.CommitIndex()
+
+	if s.readMode == LeaseReadMode {
+		if err := s.confirmLease(); err == nil {
+			return index, nil
+		}
+		// Lease couldn't be confirmed from existing contact timestamps;
+		// fall back to a real heartbeat round below.
+	}
+
+	if err := s.confirmLeadershipByHeartbeat(index); err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+// confirmLease checks whether a quorum of peers has acknowledged a
+// heartbeat recently enough that this server can be sure no other leader
+// has been elected since, without sending a fresh round of RPCs.
+func (s *server) confirmLease() error {
+	threshold := s.ElectionTimeout() - clockDriftBound
+	now := time.Now()
+
+	s.mutex.RLock()
+	voters := s.votingPeers()
+	acked, total := 1, 1+len(voters) // self
+	for _, peer := range voters {
+		if now.Sub(peer.getLastContact()) <= threshold {
+			acked++
+		}
+	}
+	s.mutex.RUnlock()
+
+	if acked*2 <= total {
+		return errLeaseExpired
+	}
+	return nil
+}
+
+// confirmLeadershipByHeartbeat sends a round of heartbeats and blocks until
+// a majority has responded, batching any other ReadIndex calls already
+// waiting on this server onto the same round.
+func (s *server) confirmLeadershipByHeartbeat(index uint64) error {
+	errc := make(chan error, 1)
+
+	s.mutex.Lock()
+	s.readIndexQueue = append(s.readIndexQueue, &readIndexWaiter{index: index, errc: errc})
+	alreadyFlushing := len(s.readIndexQueue) > 1
+	s.mutex.Unlock()
+
+	if !alreadyFlushing {
+		go s.flushReadIndexQueue()
+	}
+
+	return <-errc
+}
+
+// flushReadIndexQueue sends one round of heartbeats to every voting peer,
+// and once a majority acknowledges, releases every ReadIndex call that was
+// waiting on this server at the time the round started.
+func (s *server) flushReadIndexQueue() {
+	s.mutex.Lock()
+	waiters := s.readIndexQueue
+	s.readIndexQueue = nil
+	s.mutex.Unlock()
+
+	s.mutex.RLock()
+	voters := s.votingPeers()
+	s.mutex.RUnlock()
+
+	acked, total := 1, 1+len(voters) // self
+	for _, peer := range voters {
+		peer.flush()
+		if time.Since(peer.getLastContact()) < s.heartbeatInterval {
+			acked++
+		}
+	}
+	quorum := total/2 + 1
+
+	var err error
+	if acked < quorum {
+		err = errors.New("raft: could not confirm leadership from a majority of peers")
+	}
+
+	for _, w := range waiters {
+		w.errc <- err
+	}
+}
+
+// SetReadMode selects how ReadIndex confirms leadership before returning.
+func (s *server) SetReadMode(mode ReadMode) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.readMode = mode
+}
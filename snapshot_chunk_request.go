@@ -0,0 +1,41 @@
+package raft
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// DefaultSnapshotChunkSize is the default size, in bytes, of a single
+// snapshot chunk sent over SendSnapshotChunkRequest.
+const DefaultSnapshotChunkSize = 1024 * 1024 // 1 MiB
+
+// SnapshotChunkRequest represents a single chunk of a streamed snapshot
+// transfer. The follower assembles chunks into a temp file keyed by
+// SnapshotID and atomically renames it into place once Done is set.
+type SnapshotChunkRequest struct {
+	LeaderName string `json:"leaderName"`
+	SnapshotID string `json:"snapshotID"`
+	Offset     int64  `json:"offset"`
+	Length     int64  `json:"length"`
+	Done       bool   `json:"done"`
+	SHA256     string `json:"sha256"`
+	Data       []byte `json:"data"`
+}
+
+// Creates a new SnapshotChunkRequest.
+func newSnapshotChunkRequest(leaderName string, snapshotID string, offset int64, done bool, data []byte) *SnapshotChunkRequest {
+	return &SnapshotChunkRequest{
+		LeaderName: leaderName,
+		SnapshotID: snapshotID,
+		Offset:     offset,
+		Length:     int64(len(data)),
+		Done:       done,
+		SHA256:     sha256Hex(data),
+		Data:       data,
+	}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,16 @@
+package raft
+
+// SnapshotChunkResponse is returned by a follower after receiving a single
+// SnapshotChunkRequest.
+type SnapshotChunkResponse struct {
+	Success bool  `json:"success"`
+	Offset  int64 `json:"offset"`
+}
+
+// Creates a new SnapshotChunkResponse.
+func newSnapshotChunkResponse(success bool, offset int64) *SnapshotChunkResponse {
+	return &SnapshotChunkResponse{
+		Success: success,
+		Offset:  offset,
+	}
+}
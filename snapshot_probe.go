@@ -0,0 +1,30 @@
+package raft
+
+// SnapshotProbeRequest asks a follower how much of an in-progress,
+// interrupted snapshot transfer it has already received, so the leader can
+// resume the chunk stream from that offset instead of restarting it.
+type SnapshotProbeRequest struct {
+	LeaderName string `json:"leaderName"`
+	SnapshotID string `json:"snapshotID"`
+}
+
+// Creates a new SnapshotProbeRequest.
+func newSnapshotProbeRequest(leaderName string, snapshotID string) *SnapshotProbeRequest {
+	return &SnapshotProbeRequest{
+		LeaderName: leaderName,
+		SnapshotID: snapshotID,
+	}
+}
+
+// SnapshotProbeResponse reports how many bytes of the named snapshot
+// transfer the follower has written so far, which is the offset the next
+// chunk starts at. An offset of 0 means the follower has nothing and the
+// transfer should start over.
+type SnapshotProbeResponse struct {
+	ReceivedOffset int64 `json:"receivedOffset"`
+}
+
+// Creates a new SnapshotProbeResponse.
+func newSnapshotProbeResponse(receivedOffset int64) *SnapshotProbeResponse {
+	return &SnapshotProbeResponse{ReceivedOffset: receivedOffset}
+}
@@ -0,0 +1,78 @@
+package raft
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// snapshotChunkTempPath returns the path a follower assembles an in-progress
+// chunked snapshot transfer into before it's renamed into place.
+func (s *server) snapshotChunkTempPath(snapshotID string) string {
+	return filepath.Join(s.path, fmt.Sprintf("snapshot-%s.tmp", snapshotID))
+}
+
+// snapshotChunkFinalPath returns the path a completed chunked snapshot
+// transfer is renamed into.
+func (s *server) snapshotChunkFinalPath(snapshotID string) string {
+	return filepath.Join(s.path, fmt.Sprintf("snapshot-%s", snapshotID))
+}
+
+// processSnapshotChunkRequest writes a single chunk of a streamed snapshot
+// transfer to its offset in the temp file for SnapshotID, verifying the
+// chunk against its SHA256 before accepting it. Once Done is set, the temp
+// file is atomically renamed into place so a reader never observes a
+// partially-written snapshot file.
+func (s *server) processSnapshotChunkRequest(req *SnapshotChunkRequest) *SnapshotChunkResponse {
+	if sha256Hex(req.Data) != req.SHA256 {
+		debugln("raft.snap.chunk.corrupt: ", req.SnapshotID, " offset=", req.Offset)
+		return newSnapshotChunkResponse(false, 0)
+	}
+
+	path := s.snapshotChunkTempPath(req.SnapshotID)
+	if req.Done && req.Length == 0 {
+		// A resumed transfer that had already been renamed into place: keep
+		// the finished file rather than replacing it with an empty one.
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if _, err := os.Stat(s.snapshotChunkFinalPath(req.SnapshotID)); err == nil {
+				return newSnapshotChunkResponse(true, req.Offset)
+			}
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		debugln("raft.snap.chunk.open.failed: ", path, err)
+		return newSnapshotChunkResponse(false, 0)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteAt(req.Data, req.Offset); err != nil {
+		debugln("raft.snap.chunk.write.failed: ", path, err)
+		return newSnapshotChunkResponse(false, 0)
+	}
+
+	offset := req.Offset + req.Length
+	if req.Done {
+		if err := os.Rename(path, s.snapshotChunkFinalPath(req.SnapshotID)); err != nil {
+			debugln("raft.snap.chunk.rename.failed: ", path, err)
+			return newSnapshotChunkResponse(false, 0)
+		}
+	}
+
+	return newSnapshotChunkResponse(true, offset)
+}
+
+// processSnapshotProbeRequest reports how many bytes of an in-progress,
+// possibly-interrupted snapshot transfer this follower has already
+// received, so the leader can resume the chunk stream from that offset
+// instead of restarting it. A finished (renamed) transfer reports the full
+// file size; an unstarted one reports 0.
+func (s *server) processSnapshotProbeRequest(req *SnapshotProbeRequest) *SnapshotProbeResponse {
+	if info, err := os.Stat(s.snapshotChunkFinalPath(req.SnapshotID)); err == nil {
+		return newSnapshotProbeResponse(info.Size())
+	}
+	if info, err := os.Stat(s.snapshotChunkTempPath(req.SnapshotID)); err == nil {
+		return newSnapshotProbeResponse(info.Size())
+	}
+	return newSnapshotProbeResponse(0)
+}
@@ -0,0 +1,20 @@
+package raft
+
+// TimeoutNowRequest tells the receiving peer to start an election
+// immediately, bypassing its normal election timer. It's sent by a leader
+// that is transferring leadership away and has confirmed the target is
+// fully caught up.
+type TimeoutNowRequest struct {
+	LeaderName string
+	Term       uint64
+
+	peer *Peer
+}
+
+// Creates a new TimeoutNowRequest.
+func newTimeoutNowRequest(leaderName string, term uint64) *TimeoutNowRequest {
+	return &TimeoutNowRequest{
+		LeaderName: leaderName,
+		Term:       term,
+	}
+}
@@ -0,0 +1,13 @@
+package raft
+
+// TimeoutNowResponse acknowledges a TimeoutNowRequest.
+type TimeoutNowResponse struct {
+	Success bool
+
+	peer *Peer
+}
+
+// Creates a new TimeoutNowResponse.
+func newTimeoutNowResponse(success bool) *TimeoutNowResponse {
+	return &TimeoutNowResponse{Success: success}
+}
@@ -0,0 +1,82 @@
+package raft
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultLeadershipTransferTimeout bounds how long TransferLeadership will
+// wait for the target peer to catch up before giving up and resuming
+// normal operation.
+const DefaultLeadershipTransferTimeout = 10 * time.Second
+
+// TransferLeadership hands leadership to target without an election
+// timeout in between. It stops this server from accepting new client
+// commands, drives the existing replication path until target's log is
+// fully caught up, then sends it a TimeoutNowRequest so it starts an
+// election immediately on its current term+1. If target can't catch up
+// within DefaultLeadershipTransferTimeout, normal operation resumes and an
+// error is returned.
+func (s *server) TransferLeadership(target string) error {
+	if s.State() != Leader {
+		return ErrNotLeader
+	}
+
+	peer, ok := s.peers[target]
+	if !ok {
+		return fmt.Errorf("raft.Server: cannot transfer leadership to unknown peer %q", target)
+	}
+
+	s.mutex.Lock()
+	s.transferringLeadership = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.transferringLeadership = false
+		s.mutex.Unlock()
+	}()
+
+	deadline := time.Now().Add(DefaultLeadershipTransferTimeout)
+	for !peer.isCaughtUp() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("raft.Server: leadership transfer to %q timed out", target)
+		}
+		peer.flush()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp := peer.sendTimeoutNowRequest(newTimeoutNowRequest(s.name, s.currentTerm))
+	if resp == nil || !resp.Success {
+		return fmt.Errorf("raft.Server: %q rejected leadership transfer", target)
+	}
+	return nil
+}
+
+// TransferringLeadership reports whether this server is in the middle of a
+// TransferLeadership call. RerouteToLeader consults this to stop accepting
+// new client commands while a transfer is in progress, so a command can't
+// be acknowledged by a leader that's about to hand off to a peer that
+// never saw it.
+func (s *server) TransferringLeadership() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.transferringLeadership
+}
+
+// processTimeoutNowRequest handles an inbound TimeoutNowRequest by
+// starting an election immediately instead of waiting out the normal
+// election timer, bypassing heartbeatTicker entirely. This is the
+// receiving side TransferLeadership's target depends on to take over
+// without an election-timeout gap.
+func (s *server) processTimeoutNowRequest(req *TimeoutNowRequest) *TimeoutNowResponse {
+	s.mutex.RLock()
+	term := s.currentTerm
+	s.mutex.RUnlock()
+
+	if req.Term < term {
+		return newTimeoutNowResponse(false)
+	}
+
+	go s.elect()
+	return newTimeoutNowResponse(true)
+}
@@ -0,0 +1,28 @@
+package raft
+
+// Transporter is the interface a server uses to send every RPC type to a
+// peer. It exists so the replication, election and snapshot-transfer code
+// in Peer never has to know whether a given peer is reachable over HTTP,
+// gRPC, or an in-process channel in tests.
+//
+// A nil return from any Send* method means the request could not be
+// delivered or the peer did not reply in time; callers treat that the
+// same as a hard RPC failure.
+type Transporter interface {
+	SendAppendEntriesRequest(server Server, peer *Peer, req *AppendEntriesRequest) *AppendEntriesResponse
+	SendVoteRequest(server Server, peer *Peer, req *RequestVoteRequest) *RequestVoteResponse
+	SendSnapshotRequest(server Server, peer *Peer, req *SnapshotRequest) *SnapshotResponse
+	SendSnapshotRecoveryRequest(server Server, peer *Peer, req *SnapshotRecoveryRequest) *SnapshotRecoveryResponse
+	SendSnapshotChunkRequest(server Server, peer *Peer, req *SnapshotChunkRequest) *SnapshotChunkResponse
+	SendSnapshotProbeRequest(server Server, peer *Peer, req *SnapshotProbeRequest) *SnapshotProbeResponse
+	SendPreVoteRequest(server Server, peer *Peer, req *PreVoteRequest) *PreVoteResponse
+	SendTimeoutNowRequest(server Server, peer *Peer, req *TimeoutNowRequest) *TimeoutNowResponse
+
+	// PeerTransport returns a connection-owning transport for peer that
+	// callers needing more than strict one-request-per-round-trip RPC
+	// semantics can use directly, instead of going through the Send*
+	// methods above. Pipelined AppendEntries is the motivating case: it
+	// needs requests multiplexed over a single long-lived connection per
+	// peer rather than paying a new-connection cost per RPC.
+	PeerTransport(peer *Peer) (PeerTransport, error)
+}